@@ -0,0 +1,205 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+)
+
+// bufferedMessage is one pending publish, persisted as a line of JSON in
+// the buffer file. Payload is base64-encoded so arbitrary JSON bytes
+// survive the newline-delimited line format untouched.
+type bufferedMessage struct {
+	Topic   string `json:"topic"`
+	Payload []byte `json:"payload"`
+	Qos     byte   `json:"qos"`
+}
+
+// Buffer is a persistent FIFO queue used to store sensor readings while
+// the cloud connection is down, so no reading is lost across an outage
+// or reboot. It is backed by a plain append-only file rather than a
+// database, since the device only ever needs a handful of pending
+// messages at a time.
+type Buffer struct {
+	mu       sync.Mutex
+	path     string
+	maxBytes int64
+}
+
+const (
+	defaultBufferPath     = "/app/data/buffer.jsonl"
+	defaultBufferMaxBytes = 1 << 20 // 1 MiB
+)
+
+func getBufferPath() string {
+	path := os.Getenv("BUFFER_PATH")
+	if path == "" {
+		path = defaultBufferPath
+	}
+	return path
+}
+
+func getBufferMaxBytes() int64 {
+	maxBytes, err := strconv.ParseInt(os.Getenv("BUFFER_MAX_BYTES"), 10, 64)
+	if err != nil || maxBytes <= 0 {
+		return defaultBufferMaxBytes
+	}
+	return maxBytes
+}
+
+// NewBuffer opens (creating if necessary) the buffer file at path.
+func NewBuffer(path string, maxBytes int64) (*Buffer, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create buffer directory: %w", err)
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open buffer file: %w", err)
+	}
+	f.Close()
+
+	return &Buffer{path: path, maxBytes: maxBytes}, nil
+}
+
+// Enqueue appends a message to the buffer, trimming the oldest entries
+// if the file would otherwise grow past maxBytes.
+func (b *Buffer) Enqueue(topic string, payload []byte, qos byte) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	line, err := json.Marshal(bufferedMessage{Topic: topic, Payload: payload, Qos: qos})
+	if err != nil {
+		return fmt.Errorf("failed to marshal buffered message: %w", err)
+	}
+
+	f, err := os.OpenFile(b.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open buffer file: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to append to buffer file: %w", err)
+	}
+
+	return b.trimLocked()
+}
+
+// trimLocked drops the oldest messages until the buffer file is back
+// under maxBytes. Callers must hold b.mu.
+func (b *Buffer) trimLocked() error {
+	info, err := os.Stat(b.path)
+	if err != nil {
+		return fmt.Errorf("failed to stat buffer file: %w", err)
+	}
+	if info.Size() <= b.maxBytes {
+		return nil
+	}
+
+	lines, err := b.readLinesLocked()
+	if err != nil {
+		return err
+	}
+
+	for len(lines) > 0 && totalBytes(lines) > b.maxBytes {
+		lines = lines[1:]
+	}
+
+	return b.writeLinesLocked(lines)
+}
+
+func totalBytes(lines [][]byte) int64 {
+	var total int64
+	for _, line := range lines {
+		total += int64(len(line)) + 1
+	}
+	return total
+}
+
+func (b *Buffer) readLinesLocked() ([][]byte, error) {
+	f, err := os.Open(b.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to open buffer file: %w", err)
+	}
+	defer f.Close()
+
+	var lines [][]byte
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1<<20)
+	for scanner.Scan() {
+		line := make([]byte, len(scanner.Bytes()))
+		copy(line, scanner.Bytes())
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read buffer file: %w", err)
+	}
+
+	return lines, nil
+}
+
+func (b *Buffer) writeLinesLocked(lines [][]byte) error {
+	tmpPath := b.path + ".tmp"
+	f, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open temp buffer file: %w", err)
+	}
+
+	for _, line := range lines {
+		if _, err := f.Write(append(line, '\n')); err != nil {
+			f.Close()
+			return fmt.Errorf("failed to write temp buffer file: %w", err)
+		}
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to close temp buffer file: %w", err)
+	}
+
+	return os.Rename(tmpPath, b.path)
+}
+
+// Peek returns the oldest pending message without removing it.
+func (b *Buffer) Peek() (*bufferedMessage, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	lines, err := b.readLinesLocked()
+	if err != nil {
+		return nil, err
+	}
+	if len(lines) == 0 {
+		return nil, nil
+	}
+
+	var msg bufferedMessage
+	if err := json.Unmarshal(lines[0], &msg); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal buffered message: %w", err)
+	}
+
+	return &msg, nil
+}
+
+// Dequeue removes the oldest pending message, called once its delivery
+// has been confirmed.
+func (b *Buffer) Dequeue() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	lines, err := b.readLinesLocked()
+	if err != nil {
+		return err
+	}
+	if len(lines) == 0 {
+		return nil
+	}
+
+	return b.writeLinesLocked(lines[1:])
+}
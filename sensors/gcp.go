@@ -0,0 +1,247 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const gcpRootCAPath = "/app/cert/gcp-roots.pem"
+
+// gcpPublisher implements Publisher for Google Cloud IoT Core. Cloud IoT
+// Core authenticates MQTT connections with a JWT instead of a client
+// certificate, so the client must be reconnected with a fresh JWT before
+// the previous one expires.
+type gcpPublisher struct {
+	mu       sync.Mutex
+	client   mqtt.Client
+	opts     *mqtt.ClientOptions
+	tls      *tls.Config
+	project  string
+	ttl      time.Duration
+	alg      jwt.SigningMethod
+	signKey  interface{}
+	stopChan chan struct{}
+	subs     subscriptionTracker
+}
+
+func newGCPPublisher() (Publisher, error) {
+	project := os.Getenv("GCP_PROJECT")
+	region := os.Getenv("GCP_REGION")
+	registry := os.Getenv("GCP_REGISTRY")
+	device := os.Getenv("GCP_DEVICE")
+	privateKeyPath := os.Getenv("GCP_PRIVATE_KEY")
+	if project == "" || region == "" || registry == "" || device == "" || privateKeyPath == "" {
+		return nil, fmt.Errorf("GCP_PROJECT, GCP_REGION, GCP_REGISTRY, GCP_DEVICE and GCP_PRIVATE_KEY must all be set")
+	}
+
+	alg, signKey, err := loadGCPSigningKey(privateKeyPath, os.Getenv("JWT_ALG"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load GCP signing key: %w", err)
+	}
+
+	tlsConfig, err := loadGCPTLSConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load GCP TLS config: %w", err)
+	}
+
+	clientID := fmt.Sprintf("projects/%s/locations/%s/registries/%s/devices/%s", project, region, registry, device)
+
+	p := &gcpPublisher{
+		tls:      tlsConfig,
+		project:  project,
+		ttl:      getJWTTTL(),
+		alg:      alg,
+		signKey:  signKey,
+		stopChan: make(chan struct{}),
+	}
+
+	p.opts = mqtt.NewClientOptions().
+		AddBroker("ssl://mqtt.googleapis.com:8883").
+		SetClientID(clientID).
+		SetUsername("unused").
+		SetTLSConfig(tlsConfig).
+		SetProtocolVersion(4).
+		SetCleanSession(true).
+		SetAutoReconnect(false).
+		SetConnectionLostHandler(func(client mqtt.Client, err error) {
+			fmt.Printf("Connection lost: %v\n", err)
+			p.reconnectAsync(err)
+		})
+
+	return p, nil
+}
+
+func loadGCPSigningKey(path, alg string) (jwt.SigningMethod, interface{}, error) {
+	keyBytes, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read private key: %w", err)
+	}
+
+	switch alg {
+	case "", "RS256":
+		key, err := jwt.ParseRSAPrivateKeyFromPEM(keyBytes)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to parse RSA private key: %w", err)
+		}
+		return jwt.SigningMethodRS256, key, nil
+	case "ES256":
+		key, err := jwt.ParseECPrivateKeyFromPEM(keyBytes)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to parse EC private key: %w", err)
+		}
+		return jwt.SigningMethodES256, key, nil
+	default:
+		return nil, nil, fmt.Errorf("unsupported JWT_ALG %q (want RS256 or ES256)", alg)
+	}
+}
+
+func loadGCPTLSConfig() (*tls.Config, error) {
+	roots, err := os.ReadFile(gcpRootCAPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load Google root certificates: %w", err)
+	}
+
+	rootCAs := x509.NewCertPool()
+	if ok := rootCAs.AppendCertsFromPEM(roots); !ok {
+		return nil, fmt.Errorf("failed to append Google root certificates")
+	}
+
+	return &tls.Config{RootCAs: rootCAs}, nil
+}
+
+func getJWTTTL() time.Duration {
+	minutes, err := strconv.Atoi(os.Getenv("JWT_TTL"))
+	if err != nil || minutes <= 0 {
+		return 60 * time.Minute
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+func (p *gcpPublisher) newJWT() (string, error) {
+	now := time.Now()
+	claims := jwt.RegisteredClaims{
+		Audience:  []string{p.project},
+		IssuedAt:  jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(now.Add(p.ttl)),
+	}
+
+	token := jwt.NewWithClaims(p.alg, claims)
+	return token.SignedString(p.signKey)
+}
+
+func (p *gcpPublisher) connectLocked() error {
+	password, err := p.newJWT()
+	if err != nil {
+		return fmt.Errorf("failed to mint JWT: %w", err)
+	}
+
+	p.opts.SetPassword(password)
+	client := mqtt.NewClient(p.opts)
+	if token := client.Connect(); token.Wait() && token.Error() != nil {
+		return fmt.Errorf("failed to connect to Cloud IoT Core: %w", token.Error())
+	}
+
+	p.client = client
+	// Resubscribe against the new client: each JWT refresh or reconnect
+	// after connection loss creates an entirely new paho client, which
+	// starts out with none of the previous client's subscriptions.
+	p.subs.replay(p.client)
+	return nil
+}
+
+// reconnectAsync reconnects with a freshly minted JWT after the client
+// reports an unexpected connection loss, rather than leaving the backend
+// offline until the next scheduled refresh (up to p.ttl away).
+func (p *gcpPublisher) reconnectAsync(reason error) {
+	go func() {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+
+		log.Printf("reconnecting to Cloud IoT Core after connection loss: %v", reason)
+		if err := p.connectLocked(); err != nil {
+			log.Printf("failed to reconnect to Cloud IoT Core: %v", err)
+			return
+		}
+		appMetrics.recordReconnect()
+		fmt.Println("Reconnected to Cloud IoT Core after connection loss")
+	}()
+}
+
+func (p *gcpPublisher) Connect() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if err := p.connectLocked(); err != nil {
+		return err
+	}
+
+	go p.refreshLoop()
+	return nil
+}
+
+// refreshLoop mints a new JWT and reconnects the paho client shortly
+// before the current JWT expires, so long-running sessions don't get
+// dropped by Cloud IoT Core.
+func (p *gcpPublisher) refreshLoop() {
+	refreshEvery := p.ttl - p.ttl/10
+	ticker := time.NewTicker(refreshEvery)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			p.mu.Lock()
+			p.client.Disconnect(250)
+			if err := p.connectLocked(); err != nil {
+				log.Printf("failed to refresh Cloud IoT Core JWT: %v", err)
+			} else {
+				appMetrics.recordReconnect()
+				fmt.Println("Reconnected to Cloud IoT Core with refreshed JWT")
+			}
+			p.mu.Unlock()
+		case <-p.stopChan:
+			return
+		}
+	}
+}
+
+func (p *gcpPublisher) Publish(topic string, payload []byte, qos byte) error {
+	p.mu.Lock()
+	client := p.client
+	p.mu.Unlock()
+
+	token := client.Publish(topic, qos, false, payload)
+	token.Wait()
+	return token.Error()
+}
+
+func (p *gcpPublisher) Subscribe(topic string, handler func(payload []byte)) error {
+	p.mu.Lock()
+	client := p.client
+	p.mu.Unlock()
+
+	p.subs.add(topic, handler)
+	return subscribeClient(client, topic, handler)
+}
+
+func (p *gcpPublisher) Disconnect() {
+	close(p.stopChan)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.client.Disconnect(250)
+}
+
+func (p *gcpPublisher) IsConnected() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.client != nil && p.client.IsConnected()
+}
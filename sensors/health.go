@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// metrics tracks the counters and gauges exposed on /metrics. It is safe
+// for concurrent use since reads happen on the ticker goroutine while the
+// HTTP handlers run on their own goroutines.
+type metrics struct {
+	readsAttempted  uint64
+	readsFailed     uint64
+	publishesOK     uint64
+	publishesFailed uint64
+	reconnects      uint64
+
+	mu              sync.Mutex
+	lastTemperature float64
+	lastHumidity    float64
+	lastPublishedAt time.Time
+	hadSuccessRead  bool
+}
+
+var appMetrics = &metrics{}
+
+func (m *metrics) recordReadAttempt() {
+	atomic.AddUint64(&m.readsAttempted, 1)
+}
+
+func (m *metrics) recordReadFailure() {
+	atomic.AddUint64(&m.readsFailed, 1)
+}
+
+// recordReadSuccess marks a sensor read that returned no error, gating
+// /readyz. A sensor like the BMP280 never reports humidity, so the
+// gauges are only updated for whichever fields the reading actually has.
+func (m *metrics) recordReadSuccess(reading Reading) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if reading.Temperature != nil {
+		m.lastTemperature = *reading.Temperature
+	}
+	if reading.Humidity != nil {
+		m.lastHumidity = *reading.Humidity
+	}
+	m.hadSuccessRead = true
+}
+
+func (m *metrics) recordPublishSuccess() {
+	atomic.AddUint64(&m.publishesOK, 1)
+	m.mu.Lock()
+	m.lastPublishedAt = time.Now()
+	m.mu.Unlock()
+}
+
+func (m *metrics) recordPublishFailure() {
+	atomic.AddUint64(&m.publishesFailed, 1)
+}
+
+func (m *metrics) recordReconnect() {
+	atomic.AddUint64(&m.reconnects, 1)
+}
+
+func (m *metrics) ready(publisherConnected bool) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return publisherConnected && m.hadSuccessRead
+}
+
+func (m *metrics) secondsSinceLastPublish() float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.lastPublishedAt.IsZero() {
+		return -1
+	}
+	return time.Since(m.lastPublishedAt).Seconds()
+}
+
+func (m *metrics) render() string {
+	m.mu.Lock()
+	lastTemperature := m.lastTemperature
+	lastHumidity := m.lastHumidity
+	secondsSincePublish := -1.0
+	if !m.lastPublishedAt.IsZero() {
+		secondsSincePublish = time.Since(m.lastPublishedAt).Seconds()
+	}
+	m.mu.Unlock()
+
+	return fmt.Sprintf(
+		"# HELP sensor_reads_attempted_total Total sensor read attempts\n"+
+			"# TYPE sensor_reads_attempted_total counter\n"+
+			"sensor_reads_attempted_total %d\n"+
+			"# HELP sensor_reads_failed_total Total failed sensor reads\n"+
+			"# TYPE sensor_reads_failed_total counter\n"+
+			"sensor_reads_failed_total %d\n"+
+			"# HELP sensor_publishes_succeeded_total Total successful publishes\n"+
+			"# TYPE sensor_publishes_succeeded_total counter\n"+
+			"sensor_publishes_succeeded_total %d\n"+
+			"# HELP sensor_publishes_failed_total Total failed publishes\n"+
+			"# TYPE sensor_publishes_failed_total counter\n"+
+			"sensor_publishes_failed_total %d\n"+
+			"# HELP sensor_mqtt_reconnects_total Total MQTT reconnects\n"+
+			"# TYPE sensor_mqtt_reconnects_total counter\n"+
+			"sensor_mqtt_reconnects_total %d\n"+
+			"# HELP sensor_last_temperature_celsius Last observed temperature\n"+
+			"# TYPE sensor_last_temperature_celsius gauge\n"+
+			"sensor_last_temperature_celsius %v\n"+
+			"# HELP sensor_last_humidity_percent Last observed humidity\n"+
+			"# TYPE sensor_last_humidity_percent gauge\n"+
+			"sensor_last_humidity_percent %v\n"+
+			"# HELP sensor_seconds_since_last_publish Seconds since the last successful publish, -1 if never\n"+
+			"# TYPE sensor_seconds_since_last_publish gauge\n"+
+			"sensor_seconds_since_last_publish %v\n",
+		atomic.LoadUint64(&m.readsAttempted),
+		atomic.LoadUint64(&m.readsFailed),
+		atomic.LoadUint64(&m.publishesOK),
+		atomic.LoadUint64(&m.publishesFailed),
+		atomic.LoadUint64(&m.reconnects),
+		lastTemperature,
+		lastHumidity,
+		secondsSincePublish,
+	)
+}
+
+const (
+	httpReadHeaderTimeout = 5 * time.Second
+	httpWriteTimeout      = 10 * time.Second
+)
+
+func getHTTPAddr() string {
+	addr := os.Getenv("HTTP_ADDR")
+	if addr == "" {
+		addr = ":8080"
+	}
+	return addr
+}
+
+// startHTTPServer exposes health, readiness, metrics and last-reading
+// endpoints on the LAN so the device can be monitored without SSH access.
+func (a *App) startHTTPServer() *http.Server {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		if !appMetrics.ready(a.publisher.IsConnected()) {
+			http.Error(w, "not ready", http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		fmt.Fprint(w, appMetrics.render())
+	})
+
+	mux.HandleFunc("/last", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(a.getCurrentData()); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+
+	srv := &http.Server{
+		Addr:              getHTTPAddr(),
+		Handler:           mux,
+		ReadHeaderTimeout: httpReadHeaderTimeout,
+		WriteTimeout:      httpWriteTimeout,
+	}
+
+	go func() {
+		fmt.Printf("Starting HTTP status server on %s\n", srv.Addr)
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Printf("HTTP server error: %v", err)
+		}
+	}()
+
+	return srv
+}
+
+func shutdownHTTPServer(srv *http.Server) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		log.Printf("HTTP server shutdown error: %v", err)
+	}
+}
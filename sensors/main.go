@@ -10,6 +10,7 @@ import (
 	"os"
 	"os/signal"
 	"strconv"
+	"sync"
 	"syscall"
 	"time"
 
@@ -30,23 +31,34 @@ type SensorData struct {
 }
 
 type App struct {
-	sensor       *dht.DHT
-	sensorID     string
-	currentData  SensorData
-	interval     time.Duration
-	awsIoTClient mqtt.Client
+	mu            sync.Mutex
+	sensors       []Sensor
+	sensorID      string
+	enabled       bool
+	qos           byte
+	topicOverride string
+	currentData   SensorData
+	interval      time.Duration
+	ticker        *time.Ticker
+	publisher     Publisher
+	buffer        *Buffer
+
+	drainMu sync.Mutex
 }
 
 var awsBrokerURL = os.Getenv("AWS_BROKER")
 var topic = os.Getenv("AWS_TOPIC")
 var awsClientID = os.Getenv("AWS_CLIENT_ID")
 var sensorID = os.Getenv("ID")
+var firmwareVersion = os.Getenv("FIRMWARE_VERSION")
 
 const (
-	qos             = 0
-	rootCAPath      = "/app/cert/root-CA.crt"
-	certificatePath = "/app/cert/cert.pem"
-	privateKeyPath  = "/app/cert/private.key"
+	bufferQoS              = 1
+	rootCAPath             = "/app/cert/root-CA.crt"
+	certificatePath        = "/app/cert/cert.pem"
+	privateKeyPath         = "/app/cert/private.key"
+	defaultGPIOPin         = "GPIO2"
+	defaultFirmwareVersion = "unknown"
 )
 
 func NewApp() (*App, error) {
@@ -56,46 +68,104 @@ func NewApp() (*App, error) {
 		return nil, fmt.Errorf("failed to initialize hardware: %w", err)
 	}
 
-	sensor, err := dht.NewDHT("GPIO2", dht.Celsius, "DHT22")
+	sensors, err := buildSensors()
 	if err != nil {
-		return nil, fmt.Errorf("error creating DHT sensor: %w", err)
+		return nil, fmt.Errorf("failed to set up sensors: %w", err)
 	}
 
 	interval := getRefreshInterval()
 
-	fmt.Println("Setting up MQTT client...")
+	if firmwareVersion == "" {
+		firmwareVersion = defaultFirmwareVersion
+	}
 
-	fmt.Println("Setting up AWS IoT client...")
-	awsIoTClient, err := setupAWSIoT()
+	fmt.Println("Setting up cloud IoT publisher...")
+	publisher, err := NewPublisher()
 	if err != nil {
-		return nil, fmt.Errorf("failed to setup AWS IoT: %w", err)
+		return nil, fmt.Errorf("failed to setup cloud IoT publisher: %w", err)
+	}
+
+	if err := publisher.Connect(); err != nil {
+		return nil, fmt.Errorf("failed to connect cloud IoT publisher: %w", err)
 	}
 
-	return &App{
-		sensor:       sensor,
-		sensorID:     sensorID,
-		interval:     time.Duration(interval) * time.Second,
-		awsIoTClient: awsIoTClient,
-	}, nil
+	buffer, err := NewBuffer(getBufferPath(), getBufferMaxBytes())
+	if err != nil {
+		return nil, fmt.Errorf("failed to setup offline buffer: %w", err)
+	}
+
+	app := &App{
+		sensors:   sensors,
+		sensorID:  sensorID,
+		enabled:   true,
+		qos:       bufferQoS,
+		interval:  time.Duration(interval) * time.Second,
+		publisher: publisher,
+		buffer:    buffer,
+	}
+
+	if err := app.subscribeShadow(); err != nil {
+		return nil, fmt.Errorf("failed to set up device shadow: %w", err)
+	}
+
+	return app, nil
 }
 
 func (a *App) Run(ctx context.Context) error {
+	fmt.Println("Replaying buffered readings from any previous outage...")
+	a.drainBuffer()
+
 	fmt.Println("Starting sensor data collection...")
 	ticker := time.NewTicker(a.interval)
+	a.mu.Lock()
+	a.ticker = ticker
+	a.mu.Unlock()
 	defer ticker.Stop()
 
+	httpServer := a.startHTTPServer()
+
 	fmt.Println("Starting sensor data publishing...")
 	go a.publishSensorData(ctx, ticker)
 
+	go a.drainBufferLoop(ctx)
+
 	<-ctx.Done()
 	log.Println("Shutting down gracefully...")
 
-	// Disconnect from AWS IoT Core
-	a.awsIoTClient.Disconnect(250)
+	shutdownHTTPServer(httpServer)
+	a.publisher.Disconnect()
+	a.closeSensors()
 
 	return nil
 }
 
+func (a *App) closeSensors() {
+	a.mu.Lock()
+	sensors := a.sensors
+	a.mu.Unlock()
+
+	for _, sensor := range sensors {
+		if err := sensor.Close(); err != nil {
+			log.Printf("Failed to close sensor %s: %v", sensor.Name(), err)
+		}
+	}
+}
+
+// setCurrentData and getCurrentData guard currentData with App.mu since
+// it is written from the publish goroutine and read from the /last HTTP
+// handler on its own goroutine.
+func (a *App) setCurrentData(data SensorData) {
+	a.mu.Lock()
+	a.currentData = data
+	a.mu.Unlock()
+}
+
+func (a *App) getCurrentData() SensorData {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.currentData
+}
+
 func loadEnvVariables() {
 	err := godotenv.Load(".env")
 	if err != nil {
@@ -148,7 +218,7 @@ func loadTLSConfig() (*tls.Config, error) {
 	return tlsConfig, nil
 }
 
-func setupAWSIoT() (mqtt.Client, error) {
+func setupAWSIoT(subs *subscriptionTracker) (mqtt.Client, error) {
 	// Load TLS configuration
 	tlsConfig, err := loadTLSConfig()
 	if err != nil {
@@ -169,9 +239,13 @@ func setupAWSIoT() (mqtt.Client, error) {
 		}).
 		SetOnConnectHandler(func(client mqtt.Client) {
 			fmt.Println("Connected to AWS IoT Core")
+			subs.replay(client)
 		}).
 		SetConnectionLostHandler(func(client mqtt.Client, err error) {
 			fmt.Printf("Connection lost: %v\n", err)
+		}).
+		SetReconnectingHandler(func(client mqtt.Client, opts *mqtt.ClientOptions) {
+			appMetrics.recordReconnect()
 		})
 
 	// Connect to the broker
@@ -183,45 +257,220 @@ func setupAWSIoT() (mqtt.Client, error) {
 	return client, nil
 }
 
+const (
+	publishModeMerge    = "merge"
+	publishModeSubtopic = "subtopic"
+)
+
+func getSensorPublishMode() string {
+	if os.Getenv("SENSOR_PUBLISH_MODE") == publishModeSubtopic {
+		return publishModeSubtopic
+	}
+	return publishModeMerge
+}
+
+type sensorResult struct {
+	name    string
+	reading Reading
+	err     error
+}
+
 func (a *App) publishSensorData(ctx context.Context, ticker *time.Ticker) {
 	for {
 		select {
 		case <-ticker.C:
-			humidity, temperature, err := a.sensor.ReadRetry(11)
+			a.collectAndPublish(ctx)
+
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// collectAndPublish fans reads out across every configured sensor
+// concurrently, then either merges the results into one SensorData
+// payload or publishes one message per sensor on a subtopic, depending
+// on SENSOR_PUBLISH_MODE.
+func (a *App) collectAndPublish(ctx context.Context) {
+	a.mu.Lock()
+	enabled := a.enabled
+	sensors := a.sensors
+	qos := a.qos
+	publishTopic := topic
+	if a.topicOverride != "" {
+		publishTopic = a.topicOverride
+	}
+	a.mu.Unlock()
+
+	if !enabled || len(sensors) == 0 {
+		return
+	}
+
+	results := make([]sensorResult, len(sensors))
+	var wg sync.WaitGroup
+	for i, sensor := range sensors {
+		wg.Add(1)
+		go func(i int, sensor Sensor) {
+			defer wg.Done()
+			appMetrics.recordReadAttempt()
+			reading, err := sensor.Read(ctx)
 			if err != nil {
-				log.Printf("Read error: %v", err)
-				continue
+				appMetrics.recordReadFailure()
+			} else {
+				appMetrics.recordReadSuccess(reading)
 			}
+			results[i] = sensorResult{name: sensor.Name(), reading: reading, err: err}
+		}(i, sensor)
+	}
+	wg.Wait()
 
-			a.currentData = SensorData{
-				Humidity:    fmt.Sprintf("%v", humidity),
-				Temperature: fmt.Sprintf("%v", temperature),
-				Pressure:    "0",
-				SensorID:    a.sensorID,
-				Timestamp:   time.Now().Format(time.RFC3339),
-			}
+	timestamp := time.Now().Format(time.RFC3339)
 
-			jsonData, err := json.Marshal(a.currentData)
-			if err != nil {
-				log.Printf("Error marshalling JSON: %v", err)
+	if getSensorPublishMode() == publishModeSubtopic {
+		for _, r := range results {
+			if r.err != nil {
+				log.Printf("Read error from %s: %v", r.name, r.err)
 				continue
 			}
+			a.bufferReading(fmt.Sprintf("%s/%s", publishTopic, r.name), r.reading, timestamp, qos)
+		}
+		a.drainBuffer()
+		return
+	}
 
-			// Publish to AWS IoT Core
-			token := a.awsIoTClient.Publish(topic, qos, false, jsonData)
-			token.Wait()
-			if token.Error() != nil {
-				log.Fatalf("failed to publish message: %v", token.Error())
-			} else {
-				log.Printf("Successfully published message to topic: %s", topic)
-			}
+	merged, haveReading := mergeReadings(results, a.sensorID, timestamp)
+	if !haveReading {
+		return
+	}
+
+	a.setCurrentData(merged)
+	jsonData, err := json.Marshal(merged)
+	if err != nil {
+		log.Printf("Error marshalling JSON: %v", err)
+		return
+	}
+
+	if err := a.buffer.Enqueue(publishTopic, jsonData, qos); err != nil {
+		log.Printf("Failed to buffer reading: %v", err)
+		return
+	}
 
+	a.drainBuffer()
+}
+
+// mergeReadings combines every sensor's reading into one SensorData
+// payload, e.g. temperature/humidity from a DHT22 and pressure from a
+// BME280 on the same device.
+func mergeReadings(results []sensorResult, sensorID, timestamp string) (SensorData, bool) {
+	merged := SensorData{SensorID: sensorID, Timestamp: timestamp, Pressure: "0"}
+	haveReading := false
+
+	for _, r := range results {
+		if r.err != nil {
+			log.Printf("Read error from %s: %v", r.name, r.err)
+			continue
+		}
+		haveReading = true
+		if r.reading.Temperature != nil {
+			merged.Temperature = fmt.Sprintf("%v", *r.reading.Temperature)
+		}
+		if r.reading.Humidity != nil {
+			merged.Humidity = fmt.Sprintf("%v", *r.reading.Humidity)
+		}
+		if r.reading.Pressure != nil {
+			merged.Pressure = fmt.Sprintf("%v", *r.reading.Pressure)
+		}
+	}
+
+	return merged, haveReading
+}
+
+// bufferReading enqueues a single sensor's reading as its own message,
+// used by the "subtopic" publish mode.
+func (a *App) bufferReading(topic string, reading Reading, timestamp string, qos byte) {
+	data := SensorData{SensorID: a.sensorID, Timestamp: timestamp, Pressure: "0"}
+	if reading.Temperature != nil {
+		data.Temperature = fmt.Sprintf("%v", *reading.Temperature)
+	}
+	if reading.Humidity != nil {
+		data.Humidity = fmt.Sprintf("%v", *reading.Humidity)
+	}
+	if reading.Pressure != nil {
+		data.Pressure = fmt.Sprintf("%v", *reading.Pressure)
+	}
+
+	a.setCurrentData(data)
+	jsonData, err := json.Marshal(data)
+	if err != nil {
+		log.Printf("Error marshalling JSON: %v", err)
+		return
+	}
+
+	if err := a.buffer.Enqueue(topic, jsonData, qos); err != nil {
+		log.Printf("Failed to buffer reading: %v", err)
+	}
+}
+
+// drainBufferLoop periodically retries any readings still pending in the
+// buffer, so an outage that outlasts a single tick still gets flushed
+// once the connection recovers.
+func (a *App) drainBufferLoop(ctx context.Context) {
+	ticker := time.NewTicker(10 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			a.drainBuffer()
 		case <-ctx.Done():
 			return
 		}
 	}
 }
 
+// drainBuffer republishes pending messages in FIFO order with QoS 1,
+// removing each one only after the broker confirms delivery. It stops
+// at the first failure so ordering is preserved and retried later.
+//
+// drainMu serializes the whole peek-publish-dequeue sequence: it can be
+// called concurrently from both the publish tick and drainBufferLoop's
+// own ticker, and without a lock around the full sequence both callers
+// can publish the same head message before either removes it, so the
+// second Dequeue then discards whatever unrelated message has since
+// become the new head.
+func (a *App) drainBuffer() {
+	if !a.publisher.IsConnected() {
+		return
+	}
+
+	a.drainMu.Lock()
+	defer a.drainMu.Unlock()
+
+	for {
+		msg, err := a.buffer.Peek()
+		if err != nil {
+			log.Printf("Failed to read buffered message: %v", err)
+			return
+		}
+		if msg == nil {
+			return
+		}
+
+		if err := a.publisher.Publish(msg.Topic, msg.Payload, msg.Qos); err != nil {
+			appMetrics.recordPublishFailure()
+			log.Printf("Failed to publish buffered message: %v", err)
+			return
+		}
+		appMetrics.recordPublishSuccess()
+
+		if err := a.buffer.Dequeue(); err != nil {
+			log.Printf("Failed to remove delivered message from buffer: %v", err)
+			return
+		}
+		log.Printf("Successfully published buffered message to topic: %s", msg.Topic)
+	}
+}
+
 func main() {
 	app, err := NewApp()
 	if err != nil {
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// Publisher abstracts the cloud IoT backend so the sensor loop does not
+// need to know whether it is talking to AWS IoT Core, Google Cloud IoT
+// Core or a plain MQTT broker.
+type Publisher interface {
+	Connect() error
+	Publish(topic string, payload []byte, qos byte) error
+	Subscribe(topic string, handler func(payload []byte)) error
+	Disconnect()
+	IsConnected() bool
+}
+
+const (
+	backendAWS         = "aws"
+	backendGCP         = "gcp"
+	backendGenericMQTT = "generic-mqtt"
+)
+
+// NewPublisher builds the Publisher selected by the IOT_BACKEND env var.
+// It defaults to "aws" to preserve existing behaviour.
+func NewPublisher() (Publisher, error) {
+	backend := os.Getenv("IOT_BACKEND")
+	if backend == "" {
+		backend = backendAWS
+	}
+
+	switch backend {
+	case backendAWS:
+		return newAWSPublisher()
+	case backendGCP:
+		return newGCPPublisher()
+	case backendGenericMQTT:
+		return newGenericMQTTPublisher()
+	default:
+		return nil, fmt.Errorf("unknown IOT_BACKEND %q", backend)
+	}
+}
+
+// subscription is one handler registered through Publisher.Subscribe,
+// kept so it can be replayed against whichever client ends up serving
+// the connection next. SetAutoReconnect only restores the TCP/MQTT
+// session; paho never resubscribes previously-subscribed topics on its
+// own, so without this a single reconnect silently and permanently
+// drops every subscription (see ea24e16 for Cloud IoT Core, which needs
+// this for the same reason since it also recreates a new client on JWT
+// refresh).
+type subscription struct {
+	topic   string
+	handler func(payload []byte)
+}
+
+// subscribeClient bridges paho's mqtt.Message callback into Publisher's
+// plain []byte payload signature for a single topic.
+func subscribeClient(client mqtt.Client, topic string, handler func(payload []byte)) error {
+	token := client.Subscribe(topic, 1, func(client mqtt.Client, msg mqtt.Message) {
+		handler(msg.Payload())
+	})
+	token.Wait()
+	return token.Error()
+}
+
+// subscriptionTracker records every subscription made through a
+// Publisher so replay can be called from an OnConnectHandler (or an
+// explicit reconnect path) to restore them on the newly (re)connected
+// client.
+type subscriptionTracker struct {
+	mu   sync.Mutex
+	subs []subscription
+}
+
+func (t *subscriptionTracker) add(topic string, handler func(payload []byte)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.subs = append(t.subs, subscription{topic: topic, handler: handler})
+}
+
+func (t *subscriptionTracker) replay(client mqtt.Client) {
+	t.mu.Lock()
+	subs := append([]subscription(nil), t.subs...)
+	t.mu.Unlock()
+
+	for _, sub := range subs {
+		if err := subscribeClient(client, sub.topic, sub.handler); err != nil {
+			log.Printf("failed to resubscribe to %s: %v", sub.topic, err)
+		}
+	}
+}
+
+// awsPublisher wraps the existing AWS IoT Core MQTT client.
+type awsPublisher struct {
+	client mqtt.Client
+	subs   subscriptionTracker
+}
+
+func newAWSPublisher() (Publisher, error) {
+	p := &awsPublisher{}
+	client, err := setupAWSIoT(&p.subs)
+	if err != nil {
+		return nil, err
+	}
+	p.client = client
+	return p, nil
+}
+
+func (p *awsPublisher) Connect() error {
+	return nil // setupAWSIoT already connects
+}
+
+func (p *awsPublisher) Publish(topic string, payload []byte, qos byte) error {
+	token := p.client.Publish(topic, qos, false, payload)
+	token.Wait()
+	return token.Error()
+}
+
+func (p *awsPublisher) Subscribe(topic string, handler func(payload []byte)) error {
+	p.subs.add(topic, handler)
+	return subscribeClient(p.client, topic, handler)
+}
+
+func (p *awsPublisher) Disconnect() {
+	p.client.Disconnect(250)
+}
+
+func (p *awsPublisher) IsConnected() bool {
+	return p.client.IsConnected()
+}
+
+// genericMQTTPublisher talks to any plain MQTT broker, useful for local
+// testing or on-prem brokers that don't need per-cloud auth.
+type genericMQTTPublisher struct {
+	client mqtt.Client
+	subs   subscriptionTracker
+}
+
+func newGenericMQTTPublisher() (Publisher, error) {
+	brokerURL := os.Getenv("MQTT_BROKER")
+	clientID := os.Getenv("MQTT_CLIENT_ID")
+	if brokerURL == "" {
+		return nil, fmt.Errorf("MQTT_BROKER must be set when IOT_BACKEND=generic-mqtt")
+	}
+
+	p := &genericMQTTPublisher{}
+
+	opts := mqtt.NewClientOptions().
+		AddBroker(brokerURL).
+		SetClientID(clientID).
+		SetCleanSession(true).
+		SetAutoReconnect(true).
+		SetKeepAlive(30 * time.Second).
+		SetOnConnectHandler(func(client mqtt.Client) {
+			p.subs.replay(client)
+		}).
+		SetConnectionLostHandler(func(client mqtt.Client, err error) {
+			fmt.Printf("Connection lost: %v\n", err)
+		}).
+		SetReconnectingHandler(func(client mqtt.Client, opts *mqtt.ClientOptions) {
+			appMetrics.recordReconnect()
+		})
+
+	client := mqtt.NewClient(opts)
+	if token := client.Connect(); token.Wait() && token.Error() != nil {
+		return nil, fmt.Errorf("failed to connect to MQTT broker: %w", token.Error())
+	}
+
+	p.client = client
+	return p, nil
+}
+
+func (p *genericMQTTPublisher) Connect() error {
+	return nil
+}
+
+func (p *genericMQTTPublisher) Publish(topic string, payload []byte, qos byte) error {
+	token := p.client.Publish(topic, qos, false, payload)
+	token.Wait()
+	return token.Error()
+}
+
+func (p *genericMQTTPublisher) Subscribe(topic string, handler func(payload []byte)) error {
+	p.subs.add(topic, handler)
+	return subscribeClient(p.client, topic, handler)
+}
+
+func (p *genericMQTTPublisher) Disconnect() {
+	p.client.Disconnect(250)
+}
+
+func (p *genericMQTTPublisher) IsConnected() bool {
+	return p.client.IsConnected()
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/MichaelS11/go-dht"
+	"periph.io/x/conn/v3/i2c"
+	"periph.io/x/conn/v3/i2c/i2creg"
+	"periph.io/x/conn/v3/physic"
+	"periph.io/x/devices/v3/bmxx80"
+	"periph.io/x/host/v3"
+)
+
+// Reading is one environmental measurement. A field is nil when the
+// sensor that produced it doesn't measure that quantity, so multiple
+// readings can be merged without one sensor clobbering another's data.
+type Reading struct {
+	Temperature *float64
+	Humidity    *float64
+	Pressure    *float64
+}
+
+// Sensor is a pluggable environmental sensor. It lets the publish loop
+// fan out reads across the DHT22, BME280/BMP280 and any future sensor
+// without caring which hardware is actually attached.
+type Sensor interface {
+	Name() string
+	Read(ctx context.Context) (Reading, error)
+	Close() error
+}
+
+const sensorsEnvKey = "SENSORS"
+
+// buildSensors parses the SENSORS env var, a comma-separated list of
+// "type:param:param" entries such as "dht22:GPIO2,bme280:i2c-1:0x76", into
+// the configured Sensor plugins. It falls back to a single DHT22 on
+// defaultGPIOPin to preserve the service's original single-sensor
+// behaviour when SENSORS is unset.
+func buildSensors() ([]Sensor, error) {
+	spec := os.Getenv(sensorsEnvKey)
+	if spec == "" {
+		spec = fmt.Sprintf("dht22:%s", defaultGPIOPin)
+	}
+
+	if _, err := host.Init(); err != nil {
+		return nil, fmt.Errorf("failed to init periph host drivers: %w", err)
+	}
+
+	var sensors []Sensor
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		sensor, err := buildSensor(entry)
+		if err != nil {
+			return nil, err
+		}
+		sensors = append(sensors, sensor)
+	}
+
+	return sensors, nil
+}
+
+func buildSensor(entry string) (Sensor, error) {
+	parts := strings.Split(entry, ":")
+	kind := strings.ToLower(parts[0])
+
+	switch kind {
+	case "dht11", "dht22":
+		if len(parts) < 2 {
+			return nil, fmt.Errorf("sensor spec %q is missing a GPIO pin", entry)
+		}
+		dhtKind := "DHT22"
+		if kind == "dht11" {
+			dhtKind = "DHT11"
+		}
+		return newDHTSensor(kind, parts[1], dhtKind)
+
+	case "bme280", "bmp280":
+		if len(parts) < 3 {
+			return nil, fmt.Errorf("sensor spec %q is missing an I2C bus/address", entry)
+		}
+		addr, err := strconv.ParseUint(strings.TrimPrefix(parts[2], "0x"), 16, 16)
+		if err != nil {
+			return nil, fmt.Errorf("invalid I2C address in %q: %w", entry, err)
+		}
+		return newBMESensor(kind, parts[1], uint16(addr), kind == "bme280")
+
+	case "mock":
+		name := "mock"
+		if len(parts) > 1 {
+			name = parts[1]
+		}
+		return newMockSensor(name), nil
+
+	default:
+		return nil, fmt.Errorf("unknown sensor type %q in %s", kind, sensorsEnvKey)
+	}
+}
+
+// dhtSensor reads temperature and humidity from a DHT11/DHT22. It can be
+// reconfigured at runtime (different pin or variant) via reconfigure,
+// which the device shadow uses to apply a desired-state change.
+type dhtSensor struct {
+	name string
+
+	mu   sync.Mutex
+	pin  string
+	kind string
+	dev  *dht.DHT
+}
+
+func newDHTSensor(name, pin, kind string) (*dhtSensor, error) {
+	dev, err := dht.NewDHT(pin, dht.Celsius, kind)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s sensor on %s: %w", kind, pin, err)
+	}
+	return &dhtSensor{name: name, pin: pin, kind: kind, dev: dev}, nil
+}
+
+func (s *dhtSensor) Name() string { return s.name }
+
+func (s *dhtSensor) Read(ctx context.Context) (Reading, error) {
+	s.mu.Lock()
+	dev := s.dev
+	s.mu.Unlock()
+
+	humidity, temperature, err := dev.ReadRetry(11)
+	if err != nil {
+		return Reading{}, err
+	}
+
+	return Reading{Temperature: &temperature, Humidity: &humidity}, nil
+}
+
+func (s *dhtSensor) Close() error { return nil }
+
+// currentConfig returns the pin and DHT variant currently in use.
+func (s *dhtSensor) currentConfig() (pin, kind string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.pin, s.kind
+}
+
+// reconfigure swaps in a freshly created DHT device for a new pin and/or
+// variant without requiring the whole sensor slice to be rebuilt.
+func (s *dhtSensor) reconfigure(pin, kind string) error {
+	dev, err := dht.NewDHT(pin, dht.Celsius, kind)
+	if err != nil {
+		return fmt.Errorf("failed to create %s sensor on %s: %w", kind, pin, err)
+	}
+
+	s.mu.Lock()
+	s.dev = dev
+	s.pin = pin
+	s.kind = kind
+	s.mu.Unlock()
+
+	return nil
+}
+
+// bmeSensor reads temperature and pressure (and humidity, on the BME280)
+// from a BMx280 over I2C.
+type bmeSensor struct {
+	name        string
+	bus         i2c.BusCloser
+	dev         *bmxx80.Dev
+	hasHumidity bool
+}
+
+func newBMESensor(name, busName string, addr uint16, hasHumidity bool) (*bmeSensor, error) {
+	bus, err := i2creg.Open(busName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open I2C bus %s: %w", busName, err)
+	}
+
+	opts := &bmxx80.Opts{Temperature: bmxx80.O4x, Pressure: bmxx80.O4x}
+	if hasHumidity {
+		opts.Humidity = bmxx80.O4x
+	}
+
+	dev, err := bmxx80.NewI2C(bus, addr, opts)
+	if err != nil {
+		bus.Close()
+		return nil, fmt.Errorf("failed to init %s at %#x on %s: %w", name, addr, busName, err)
+	}
+
+	return &bmeSensor{name: name, bus: bus, dev: dev, hasHumidity: hasHumidity}, nil
+}
+
+func (s *bmeSensor) Name() string { return s.name }
+
+func (s *bmeSensor) Read(ctx context.Context) (Reading, error) {
+	var env physic.Env
+	if err := s.dev.Sense(&env); err != nil {
+		return Reading{}, err
+	}
+
+	temperature := env.Temperature.Celsius()
+	pressure := float64(env.Pressure) / float64(physic.Pascal) / 100 // hPa
+	reading := Reading{Temperature: &temperature, Pressure: &pressure}
+
+	if s.hasHumidity {
+		humidity := float64(env.Humidity) / float64(physic.PercentRH)
+		reading.Humidity = &humidity
+	}
+
+	return reading, nil
+}
+
+func (s *bmeSensor) Close() error {
+	if err := s.dev.Halt(); err != nil {
+		s.bus.Close()
+		return fmt.Errorf("failed to halt %s: %w", s.name, err)
+	}
+	return s.bus.Close()
+}
+
+// mockSensor returns a fixed reading without touching any hardware, for
+// local development and testing on machines with no sensors attached.
+type mockSensor struct {
+	name string
+}
+
+func newMockSensor(name string) *mockSensor {
+	return &mockSensor{name: name}
+}
+
+func (s *mockSensor) Name() string { return s.name }
+
+func (s *mockSensor) Read(ctx context.Context) (Reading, error) {
+	temperature, humidity, pressure := 21.5, 45.0, 1013.25
+	return Reading{Temperature: &temperature, Humidity: &humidity, Pressure: &pressure}, nil
+}
+
+func (s *mockSensor) Close() error { return nil }
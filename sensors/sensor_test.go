@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestBuildSensor(t *testing.T) {
+	tests := []struct {
+		name     string
+		entry    string
+		wantName string
+		wantErr  bool
+	}{
+		{name: "mock default name", entry: "mock", wantName: "mock"},
+		{name: "mock custom name", entry: "mock:outdoor", wantName: "outdoor"},
+		{name: "dht22 missing pin", entry: "dht22", wantErr: true},
+		{name: "bme280 missing i2c address", entry: "bme280:i2c-1", wantErr: true},
+		{name: "bme280 invalid i2c address", entry: "bme280:i2c-1:not-hex", wantErr: true},
+		{name: "unknown sensor type", entry: "lidar:GPIO2", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sensor, err := buildSensor(tt.entry)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("buildSensor(%q) = nil error, want error", tt.entry)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("buildSensor(%q) returned unexpected error: %v", tt.entry, err)
+			}
+			if sensor.Name() != tt.wantName {
+				t.Errorf("buildSensor(%q).Name() = %q, want %q", tt.entry, sensor.Name(), tt.wantName)
+			}
+		})
+	}
+}
+
+func TestMockSensorRead(t *testing.T) {
+	sensor := newMockSensor("mock")
+
+	reading, err := sensor.Read(context.Background())
+	if err != nil {
+		t.Fatalf("Read() returned unexpected error: %v", err)
+	}
+	if reading.Temperature == nil || reading.Humidity == nil || reading.Pressure == nil {
+		t.Fatalf("Read() = %+v, want all fields populated", reading)
+	}
+}
+
+func TestMergeReadings(t *testing.T) {
+	temp, hum, pressure := 21.5, 45.0, 1013.25
+
+	tests := []struct {
+		name            string
+		results         []sensorResult
+		wantHaveReading bool
+		wantTemperature string
+		wantHumidity    string
+		wantPressure    string
+	}{
+		{
+			name: "single sensor success",
+			results: []sensorResult{
+				{name: "mock", reading: Reading{Temperature: &temp, Humidity: &hum}},
+			},
+			wantHaveReading: true,
+			wantTemperature: "21.5",
+			wantHumidity:    "45",
+			wantPressure:    "0",
+		},
+		{
+			name: "merges temperature/humidity and pressure from separate sensors",
+			results: []sensorResult{
+				{name: "dht22", reading: Reading{Temperature: &temp, Humidity: &hum}},
+				{name: "bme280", reading: Reading{Pressure: &pressure}},
+			},
+			wantHaveReading: true,
+			wantTemperature: "21.5",
+			wantHumidity:    "45",
+			wantPressure:    "1013.25",
+		},
+		{
+			name: "all sensors failed",
+			results: []sensorResult{
+				{name: "mock", err: errBoom},
+			},
+			wantHaveReading: false,
+		},
+		{
+			name: "one sensor failed, one succeeded",
+			results: []sensorResult{
+				{name: "mock", err: errBoom},
+				{name: "dht22", reading: Reading{Temperature: &temp, Humidity: &hum}},
+			},
+			wantHaveReading: true,
+			wantTemperature: "21.5",
+			wantHumidity:    "45",
+			wantPressure:    "0",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			merged, haveReading := mergeReadings(tt.results, "sensor-1", "2026-07-29T00:00:00Z")
+			if haveReading != tt.wantHaveReading {
+				t.Fatalf("mergeReadings() haveReading = %v, want %v", haveReading, tt.wantHaveReading)
+			}
+			if !tt.wantHaveReading {
+				return
+			}
+			if merged.Temperature != tt.wantTemperature {
+				t.Errorf("Temperature = %q, want %q", merged.Temperature, tt.wantTemperature)
+			}
+			if merged.Humidity != tt.wantHumidity {
+				t.Errorf("Humidity = %q, want %q", merged.Humidity, tt.wantHumidity)
+			}
+			if merged.Pressure != tt.wantPressure {
+				t.Errorf("Pressure = %q, want %q", merged.Pressure, tt.wantPressure)
+			}
+			if merged.SensorID != "sensor-1" {
+				t.Errorf("SensorID = %q, want %q", merged.SensorID, "sensor-1")
+			}
+		})
+	}
+}
+
+var errBoom = errors.New("boom")
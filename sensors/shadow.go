@@ -0,0 +1,160 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+)
+
+const (
+	shadowDeltaTopicFmt  = "$aws/things/%s/shadow/update/delta"
+	shadowUpdateTopicFmt = "$aws/things/%s/shadow/update"
+)
+
+// shadowDesired mirrors the subset of a Device Shadow's desired state
+// this firmware understands. Pointers distinguish "not present in this
+// delta" from a deliberate zero value.
+type shadowDesired struct {
+	RefreshInterval *int    `json:"refresh_interval,omitempty"`
+	SensorType      *string `json:"sensor_type,omitempty"`
+	GPIOPin         *string `json:"gpio_pin,omitempty"`
+	Enabled         *bool   `json:"enabled,omitempty"`
+	Qos             *byte   `json:"qos,omitempty"`
+	TopicOverride   *string `json:"topic_override,omitempty"`
+}
+
+type shadowDeltaDocument struct {
+	State shadowDesired `json:"state"`
+}
+
+// subscribeShadow wires up the Device Shadow delta topic so the device
+// can be reconfigured remotely without SSH access, and publishes the
+// current reported state so the cloud side has an accurate baseline.
+func (a *App) subscribeShadow() error {
+	deltaTopic := fmt.Sprintf(shadowDeltaTopicFmt, a.sensorID)
+	if err := a.publisher.Subscribe(deltaTopic, a.handleShadowDelta); err != nil {
+		return fmt.Errorf("failed to subscribe to shadow delta topic: %w", err)
+	}
+
+	sensorNames := make([]string, len(a.sensors))
+	for i, sensor := range a.sensors {
+		sensorNames[i] = sensor.Name()
+	}
+
+	reported := map[string]interface{}{
+		"firmware_version": firmwareVersion,
+		"sensor_id":        a.sensorID,
+		"refresh_interval": int(a.interval.Seconds()),
+		"sensors":          sensorNames,
+		"enabled":          a.enabled,
+	}
+
+	if dhtSensor := a.firstDHTSensor(); dhtSensor != nil {
+		pin, kind := dhtSensor.currentConfig()
+		reported["gpio_pin"] = pin
+		reported["sensor_type"] = kind
+	}
+
+	return a.publishShadowReported(reported)
+}
+
+// firstDHTSensor returns the first configured DHT sensor, if any, so the
+// shadow handler knows which one to reconfigure on a pin/type change.
+func (a *App) firstDHTSensor() *dhtSensor {
+	for _, sensor := range a.sensors {
+		if dht, ok := sensor.(*dhtSensor); ok {
+			return dht
+		}
+	}
+	return nil
+}
+
+// handleShadowDelta applies desired-state fields from a shadow delta
+// message and reports back the state that was actually applied.
+func (a *App) handleShadowDelta(payload []byte) {
+	var delta shadowDeltaDocument
+	if err := json.Unmarshal(payload, &delta); err != nil {
+		log.Printf("Failed to parse shadow delta: %v", err)
+		return
+	}
+
+	reported := map[string]interface{}{}
+
+	a.mu.Lock()
+	if delta.State.RefreshInterval != nil {
+		if *delta.State.RefreshInterval <= 0 {
+			log.Printf("Ignoring shadow refresh_interval %d: must be positive", *delta.State.RefreshInterval)
+		} else {
+			a.interval = time.Duration(*delta.State.RefreshInterval) * time.Second
+			if a.ticker != nil {
+				a.ticker.Reset(a.interval)
+			}
+			reported["refresh_interval"] = *delta.State.RefreshInterval
+		}
+	}
+
+	if delta.State.SensorType != nil || delta.State.GPIOPin != nil {
+		if dhtSensor := a.firstDHTSensor(); dhtSensor == nil {
+			log.Printf("Shadow requested a DHT reconfiguration but no DHT sensor is configured")
+		} else {
+			pin, kind := dhtSensor.currentConfig()
+			if delta.State.SensorType != nil {
+				kind = *delta.State.SensorType
+			}
+			if delta.State.GPIOPin != nil {
+				pin = *delta.State.GPIOPin
+			}
+
+			if err := dhtSensor.reconfigure(pin, kind); err != nil {
+				log.Printf("Failed to reconfigure DHT sensor: %v", err)
+			} else {
+				reported["sensor_type"] = kind
+				reported["gpio_pin"] = pin
+			}
+		}
+	}
+
+	if delta.State.Enabled != nil {
+		a.enabled = *delta.State.Enabled
+		reported["enabled"] = *delta.State.Enabled
+	}
+
+	if delta.State.Qos != nil {
+		a.qos = *delta.State.Qos
+		reported["qos"] = *delta.State.Qos
+	}
+
+	if delta.State.TopicOverride != nil {
+		a.topicOverride = *delta.State.TopicOverride
+		reported["topic_override"] = *delta.State.TopicOverride
+	}
+	a.mu.Unlock()
+
+	if len(reported) == 0 {
+		return
+	}
+
+	if err := a.publishShadowReported(reported); err != nil {
+		log.Printf("Failed to report shadow state: %v", err)
+	}
+}
+
+// publishShadowReported enqueues the reported-state document through the
+// offline buffer, the same path every other payload takes, so a shadow
+// report sent while the device is disconnected is replayed once the
+// connection recovers instead of being silently dropped.
+func (a *App) publishShadowReported(reported map[string]interface{}) error {
+	doc := map[string]interface{}{"state": map[string]interface{}{"reported": reported}}
+	payload, err := json.Marshal(doc)
+	if err != nil {
+		return fmt.Errorf("failed to marshal shadow report: %w", err)
+	}
+
+	if err := a.buffer.Enqueue(fmt.Sprintf(shadowUpdateTopicFmt, a.sensorID), payload, bufferQoS); err != nil {
+		return fmt.Errorf("failed to buffer shadow report: %w", err)
+	}
+
+	a.drainBuffer()
+	return nil
+}